@@ -0,0 +1,177 @@
+package main
+
+import (
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/sealdice/javascript/tools/forge"
+)
+
+const feedDomain = "sealdice.com"
+
+// atomFeed mirrors the subset of the Atom 1.0 syndication format
+// (RFC 4287) that we need to describe plugin updates.
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr,omitempty"`
+	Href string `xml:"href,attr"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomEntry struct {
+	ID      string     `xml:"id"`
+	Title   string     `xml:"title"`
+	Updated string     `xml:"updated"`
+	Author  atomAuthor `xml:"author"`
+	Summary string     `xml:"summary"`
+	Links   []atomLink `xml:"link"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+// outputAtom writes an Atom 1.0 feed of plugin updates to path, with
+// entries sorted by UpdateTime descending so feed readers surface the
+// most recently updated plugins first.
+func outputAtom(metas []pluginMeta, path string) error {
+	sorted := make([]pluginMeta, len(metas))
+	copy(sorted, metas)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].UpdateTime > sorted[j].UpdateTime
+	})
+
+	// buildTime is the fallback for any <updated> that would otherwise be
+	// empty (no parseable UpdateTime), since RFC 4287 requires atom:updated
+	// to be a non-empty date-time on both the feed and every entry.
+	buildTime := time.Now()
+
+	feed := atomFeed{
+		Title: "SealDice JavaScript Plugins",
+		ID:    "tag:" + feedDomain + ",2024:scripts",
+		Links: []atomLink{
+			{Rel: "self", Href: "https://github.com/sealdice/javascript/scripts.atom", Type: "application/atom+xml"},
+		},
+	}
+
+	var latest string
+	for _, meta := range sorted {
+		if meta.UpdateTime > latest {
+			latest = meta.UpdateTime
+		}
+
+		entry := atomEntry{
+			ID:      entryID(meta.Path),
+			Title:   entryTitle(meta),
+			Updated: entryUpdated(meta.UpdateTime, buildTime),
+			Author:  atomAuthor{Name: meta.Author},
+			Summary: meta.Description,
+			Links: []atomLink{
+				{Rel: "alternate", Href: meta.DownloadURL},
+				{Rel: "enclosure", Href: meta.DownloadURL, Type: "text/javascript"},
+			},
+		}
+		feed.Entries = append(feed.Entries, entry)
+	}
+	feed.Updated = entryUpdated(latest, buildTime)
+
+	out, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	if _, err := out.WriteString(xml.Header); err != nil {
+		return err
+	}
+	if _, err := out.WriteString(`<?xml-stylesheet type="text/xsl" href="scripts.xsl"?>` + "\n"); err != nil {
+		return err
+	}
+
+	enc := xml.NewEncoder(out)
+	enc.Indent("", "  ")
+	return enc.Encode(feed)
+}
+
+// entryID builds a tag URI (RFC 4151) for a plugin, scoped to
+// feedDomain so entry identities survive the repo moving hosts. The path is
+// percent-escaped the same way as DownloadURL/MirrorURLs so it stays a
+// legal tag URI even when the plugin's path contains spaces or other
+// characters that aren't valid pchars.
+func entryID(pluginPath string) string {
+	return "tag:" + feedDomain + ",2024:" + forge.EscapePath(filepath.ToSlash(pluginPath))
+}
+
+func entryTitle(meta pluginMeta) string {
+	if meta.Version == "" {
+		return meta.Name
+	}
+	return meta.Name + " " + meta.Version
+}
+
+// entryUpdated parses the UpdateTime produced by handleFile (time.DateTime
+// layout) and renders it as RFC 3339, as required by the Atom spec. When
+// updateTime is empty or unparseable, it falls back to fallback rather than
+// emitting an empty atom:updated, which RFC 4287 forbids.
+func entryUpdated(updateTime string, fallback time.Time) string {
+	if updateTime != "" {
+		if t, err := time.ParseInLocation(time.DateTime, updateTime, time.Local); err == nil {
+			return t.Format(time.RFC3339)
+		}
+	}
+	return fallback.Format(time.RFC3339)
+}
+
+// outputAtomStylesheet writes the scripts.xsl companion stylesheet that
+// lets browsers render scripts.atom as an HTML table instead of raw XML.
+func outputAtomStylesheet(path string) error {
+	return os.WriteFile(path, []byte(atomXSL), 0o644)
+}
+
+const atomXSL = `<?xml version="1.0" encoding="UTF-8"?>
+<xsl:stylesheet version="1.0" xmlns:xsl="http://www.w3.org/1999/XSL/Transform" xmlns:atom="http://www.w3.org/2005/Atom">
+  <xsl:output method="html" encoding="UTF-8" indent="yes"/>
+  <xsl:template match="/atom:feed">
+    <html>
+      <head>
+        <title><xsl:value-of select="atom:title"/></title>
+      </head>
+      <body>
+        <h1><xsl:value-of select="atom:title"/></h1>
+        <table border="1" cellpadding="6">
+          <tr>
+            <th>Plugin</th>
+            <th>Updated</th>
+            <th>Description</th>
+          </tr>
+          <xsl:for-each select="atom:entry">
+            <tr>
+              <td>
+                <a>
+                  <xsl:attribute name="href">
+                    <xsl:value-of select="atom:link[@rel='alternate']/@href"/>
+                  </xsl:attribute>
+                  <xsl:value-of select="atom:title"/>
+                </a>
+              </td>
+              <td><xsl:value-of select="atom:updated"/></td>
+              <td><xsl:value-of select="atom:summary"/></td>
+            </tr>
+          </xsl:for-each>
+        </table>
+      </body>
+    </html>
+  </xsl:template>
+</xsl:stylesheet>
+`
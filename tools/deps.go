@@ -0,0 +1,164 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+// resolveDeps validates the Depents dependency graph declared across metas
+// and returns them in a stable topological order (dependencies before
+// dependents, ties broken by Path), with DependsResolved populated with the
+// transitive closure of each plugin's required plugins.
+//
+// It rejects the build with a clear error if a plugin depends on a name
+// that doesn't exist, if two plugins declare the same name, or if the graph
+// contains a cycle.
+func resolveDeps(metas []pluginMeta) ([]pluginMeta, error) {
+	byName := make(map[string]int, len(metas))
+	for i, meta := range metas {
+		if meta.Name == "" {
+			continue
+		}
+		if j, dup := byName[meta.Name]; dup {
+			return nil, fmt.Errorf("duplicate plugin name %q declared by %q and %q", meta.Name, metas[j].Path, meta.Path)
+		}
+		byName[meta.Name] = i
+	}
+
+	// inDegree[i] counts how many plugins i depends on that haven't been
+	// emitted yet; dependents[i] lists the plugins that depend on i.
+	inDegree := make([]int, len(metas))
+	dependents := make([][]int, len(metas))
+
+	for i, meta := range metas {
+		for _, dep := range meta.Depents {
+			j, ok := byName[dep]
+			if !ok {
+				return nil, fmt.Errorf("plugin %q depends on unknown plugin %q", meta.Name, dep)
+			}
+			inDegree[i]++
+			dependents[j] = append(dependents[j], i)
+		}
+	}
+
+	ready := make([]int, 0, len(metas))
+	for i := range metas {
+		if inDegree[i] == 0 {
+			ready = append(ready, i)
+		}
+	}
+
+	ordered := make([]pluginMeta, 0, len(metas))
+	closures := make([][]string, len(metas))
+
+	for len(ready) > 0 {
+		sort.Slice(ready, func(a, b int) bool { return metas[ready[a]].Path < metas[ready[b]].Path })
+		i := ready[0]
+		ready = ready[1:]
+
+		closures[i] = transitiveClosure(metas[i], byName, closures)
+		metas[i].DependsResolved = closures[i]
+		ordered = append(ordered, metas[i])
+
+		for _, j := range dependents[i] {
+			inDegree[j]--
+			if inDegree[j] == 0 {
+				ready = append(ready, j)
+			}
+		}
+	}
+
+	if len(ordered) != len(metas) {
+		cycle := findCycle(metas, byName)
+		return nil, fmt.Errorf("dependency cycle detected: %s", cycle)
+	}
+
+	return ordered, nil
+}
+
+// transitiveClosure computes the sorted, deduplicated set of plugin names
+// required by meta, direct or indirect. It relies on closures already being
+// populated for every plugin meta directly depends on, which resolveDeps
+// guarantees by processing nodes in topological order.
+func transitiveClosure(meta pluginMeta, byName map[string]int, closures [][]string) []string {
+	seen := map[string]bool{}
+	for _, dep := range meta.Depents {
+		seen[dep] = true
+		for _, transitive := range closures[byName[dep]] {
+			seen[transitive] = true
+		}
+	}
+
+	out := make([]string, 0, len(seen))
+	for name := range seen {
+		out = append(out, name)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// findCycle locates one cycle among plugins that couldn't be scheduled by
+// Kahn's algorithm, for a human-readable error message such as
+// `A -> B -> C -> A`.
+func findCycle(metas []pluginMeta, byName map[string]int) string {
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := make([]int, len(metas))
+	var path []int
+
+	var visit func(i int) []int
+	visit = func(i int) []int {
+		state[i] = visiting
+		path = append(path, i)
+
+		for _, dep := range metas[i].Depents {
+			j, ok := byName[dep]
+			if !ok {
+				continue
+			}
+			switch state[j] {
+			case visiting:
+				start := 0
+				for k, node := range path {
+					if node == j {
+						start = k
+						break
+					}
+				}
+				return append(append([]int{}, path[start:]...), j)
+			case unvisited:
+				if cycle := visit(j); cycle != nil {
+					return cycle
+				}
+			}
+		}
+
+		path = path[:len(path)-1]
+		state[i] = done
+		return nil
+	}
+
+	for i := range metas {
+		if state[i] == unvisited {
+			if cycle := visit(i); cycle != nil {
+				names := make([]string, len(cycle))
+				for k, idx := range cycle {
+					names[k] = metas[idx].Name
+				}
+				return joinArrow(names)
+			}
+		}
+	}
+	return "(unknown)"
+}
+
+func joinArrow(names []string) string {
+	out := names[0]
+	for _, name := range names[1:] {
+		out += " -> " + name
+	}
+	return out
+}
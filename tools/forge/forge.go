@@ -0,0 +1,105 @@
+// Package forge abstracts over the various code-hosting mirrors a plugin's
+// raw source can be fetched from, so the index builder isn't locked to a
+// single hard-coded GitHub raw URL.
+package forge
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// Forger resolves the location of a file within a repository on a
+// particular code-hosting forge or mirror.
+type Forger interface {
+	// Name identifies the forge, e.g. "github", "jsdelivr".
+	Name() string
+	// RawURL returns the URL to fetch path at the forge's tracked branch.
+	RawURL(path string) string
+	// ReleaseURL returns the URL to fetch path as it was tagged at version.
+	ReleaseURL(path, version string) string
+}
+
+// EscapePath percent-escapes each segment of a slash-separated repository
+// path, leaving the slashes themselves intact. It's exported so callers
+// that build other kinds of URLs or identifiers from the same plugin path
+// (e.g. the Atom feed's entry ids) stay consistent with the forges.
+func EscapePath(p string) string {
+	parts := strings.Split(p, "/")
+	for i := range parts {
+		parts[i] = url.PathEscape(parts[i])
+	}
+	return path.Join(parts...)
+}
+
+// GitHubRaw resolves files via raw.githubusercontent.com, the default
+// and most widely reachable forge.
+type GitHubRaw struct {
+	Owner  string
+	Repo   string
+	Branch string
+}
+
+func (g GitHubRaw) Name() string { return "github" }
+
+func (g GitHubRaw) RawURL(p string) string {
+	return fmt.Sprintf("https://raw.githubusercontent.com/%s/%s/%s/%s", g.Owner, g.Repo, g.Branch, EscapePath(p))
+}
+
+func (g GitHubRaw) ReleaseURL(p, version string) string {
+	return fmt.Sprintf("https://github.com/%s/%s/raw/refs/tags/%s/%s", g.Owner, g.Repo, version, EscapePath(p))
+}
+
+// JsDelivr resolves files via the jsDelivr GitHub CDN, a fast fallback
+// for networks where raw.githubusercontent.com is blocked or slow.
+type JsDelivr struct {
+	Owner  string
+	Repo   string
+	Branch string
+}
+
+func (j JsDelivr) Name() string { return "jsdelivr" }
+
+func (j JsDelivr) RawURL(p string) string {
+	return fmt.Sprintf("https://cdn.jsdelivr.net/gh/%s/%s@%s/%s", j.Owner, j.Repo, j.Branch, EscapePath(p))
+}
+
+func (j JsDelivr) ReleaseURL(p, version string) string {
+	return fmt.Sprintf("https://cdn.jsdelivr.net/gh/%s/%s@%s/%s", j.Owner, j.Repo, version, EscapePath(p))
+}
+
+// GhProxy wraps another Forger's GitHub URLs with the ghproxy.com
+// reverse proxy, commonly used where GitHub itself is unreachable.
+type GhProxy struct {
+	Upstream Forger
+}
+
+func (g GhProxy) Name() string { return "ghproxy" }
+
+func (g GhProxy) RawURL(p string) string {
+	return "https://ghproxy.com/" + g.Upstream.RawURL(p)
+}
+
+func (g GhProxy) ReleaseURL(p, version string) string {
+	return "https://ghproxy.com/" + g.Upstream.ReleaseURL(p, version)
+}
+
+// Gitea resolves files hosted on a self-hosted or third-party Gitea
+// instance, for mirrors that don't live on GitHub at all.
+type Gitea struct {
+	BaseURL string
+	Owner   string
+	Repo    string
+	Branch  string
+}
+
+func (g Gitea) Name() string { return "gitea" }
+
+func (g Gitea) RawURL(p string) string {
+	return fmt.Sprintf("%s/%s/%s/raw/branch/%s/%s", strings.TrimSuffix(g.BaseURL, "/"), g.Owner, g.Repo, g.Branch, EscapePath(p))
+}
+
+func (g Gitea) ReleaseURL(p, version string) string {
+	return fmt.Sprintf("%s/%s/%s/raw/tag/%s/%s", strings.TrimSuffix(g.BaseURL, "/"), g.Owner, g.Repo, version, EscapePath(p))
+}
@@ -3,10 +3,10 @@ package main
 import (
 	"encoding/json"
 	"errors"
+	"flag"
 	"fmt"
 	"io/fs"
 	"log/slog"
-	"net/url"
 	"os"
 	"path/filepath"
 	"regexp"
@@ -15,12 +15,13 @@ import (
 	"time"
 
 	"github.com/golang-module/carbon"
+
+	"github.com/sealdice/javascript/tools/forge"
 )
 
 var (
-	reMeta      = regexp.MustCompile(`(?s)//[ \t]*==UserScript==[ \t]*\r?\n(.*)//[ \t]*==/UserScript==`)
-	reItem      = regexp.MustCompile(`//[ \t]*@(\S+)\s+([^\r\n]+)`)
-	ghURLPrefix = "https://raw.githubusercontent.com/sealdice/javascript/main/"
+	reMeta = regexp.MustCompile(`(?s)//[ \t]*==UserScript==[ \t]*\r?\n(.*)//[ \t]*==/UserScript==`)
+	reItem = regexp.MustCompile(`//[ \t]*@(\S+)\s+([^\r\n]+)`)
 )
 
 type pluginMeta struct {
@@ -36,33 +37,93 @@ type pluginMeta struct {
 	Etag        string   `json:"etag,omitempty"`
 	Depents     []string `json:"depents,omitempty"`
 
-	DownloadURL string `json:"download_url,omitempty"`
+	Namespace    string            `json:"namespace,omitempty"`
+	Icon         string            `json:"icon,omitempty"`
+	Matches      []string          `json:"matches,omitempty"`
+	Includes     []string          `json:"includes,omitempty"`
+	Excludes     []string          `json:"excludes,omitempty"`
+	Grants       []string          `json:"grants,omitempty"`
+	Requires     []string          `json:"requires,omitempty"`
+	SupportURL   string            `json:"support_url,omitempty"`
+	RunAt        string            `json:"run_at,omitempty"`
+	Names        map[string]string `json:"names,omitempty"`
+	Descriptions map[string]string `json:"descriptions,omitempty"`
+
+	DownloadURL     string   `json:"download_url,omitempty"`
+	MirrorURLs      []string `json:"mirror_urls,omitempty"`
+	DependsResolved []string `json:"depends_resolved,omitempty"`
 }
 
+var (
+	flagOwner      = flag.String("owner", "sealdice", "repository owner used to build forge/mirror URLs")
+	flagRepo       = flag.String("repo", "javascript", "repository name used to build forge/mirror URLs")
+	flagBranch     = flag.String("branch", "main", "tracked branch used to build forge/mirror URLs")
+	flagMirrors    = flag.String("mirrors", "jsdelivr,ghproxy", "comma-separated list of mirrors to populate MirrorURLs with (jsdelivr, ghproxy, gitea)")
+	flagGiteaURL   = flag.String("gitea-base-url", "", "base URL of a Gitea mirror instance, required when \"gitea\" is in -mirrors")
+	flagGiteaOwner = flag.String("gitea-owner", "", "owner of the repository on the Gitea mirror, defaults to -owner")
+	flagGiteaRepo  = flag.String("gitea-repo", "", "name of the repository on the Gitea mirror, defaults to -repo")
+
+	flagValidate  = flag.Bool("validate", false, "revalidate already-known plugins against their UpdateURLs via conditional requests instead of assuming they are unchanged")
+	flagStaleness = flag.Duration("staleness", defaultStaleness, "how long a cached revalidation result is trusted before -validate re-checks it")
+)
+
 func main() {
 	const rootPath = "./scripts"
 	const outputPath = "./scripts.json"
+	const outputAtomPath = "./scripts.atom"
+	const outputAtomXSLPath = "./scripts.xsl"
 
 	slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stderr, nil)))
 
-	if len(os.Args) > 1 {
-		wd := os.Args[1]
+	flag.Parse()
+
+	if wd := flag.Arg(0); wd != "" {
 		if err := os.Chdir(wd); err != nil {
 			slog.Error("failed to change working directory", "error", err)
 			os.Exit(1)
 		}
 	}
 
-	metas, err := walkJS(rootPath)
+	primary, mirrors, err := buildForges()
+	if err != nil {
+		slog.Error("failed to configure forges", "error", err.Error())
+		os.Exit(1)
+	}
+
+	metas, err := walkJS(rootPath, primary, mirrors)
 	if err != nil {
 		slog.Error("failed to walk javascript files", "error", err.Error())
 		os.Exit(1)
 	}
 
+	if *flagValidate {
+		metas, err = validate(metas, outputPath, defaultCachePath, *flagStaleness, primary, mirrors)
+		if err != nil {
+			slog.Error("failed to validate against upstream", "error", err.Error())
+			os.Exit(1)
+		}
+	}
+
+	metas, err = resolveDeps(metas)
+	if err != nil {
+		slog.Error("failed to resolve plugin dependencies", "error", err.Error())
+		os.Exit(1)
+	}
+
 	if err := output(metas, outputPath); err != nil {
 		slog.Error("failed to output", "error", err.Error())
 		os.Exit(1)
 	}
+
+	if err := outputAtom(metas, outputAtomPath); err != nil {
+		slog.Error("failed to output atom feed", "error", err.Error())
+		os.Exit(1)
+	}
+
+	if err := outputAtomStylesheet(outputAtomXSLPath); err != nil {
+		slog.Error("failed to output atom stylesheet", "error", err.Error())
+		os.Exit(1)
+	}
 }
 
 func output(metas []pluginMeta, path string) error {
@@ -77,7 +138,42 @@ func output(metas []pluginMeta, path string) error {
 	return enc.Encode(metas)
 }
 
-func walkJS(rootPath string) ([]pluginMeta, error) {
+// buildForges assembles the primary forge used for DownloadURL and the
+// set of mirror forges used for MirrorURLs, from the -owner/-repo/-branch
+// and -mirrors flags.
+func buildForges() (forge.Forger, []forge.Forger, error) {
+	primary := forge.GitHubRaw{Owner: *flagOwner, Repo: *flagRepo, Branch: *flagBranch}
+
+	var mirrors []forge.Forger
+	for _, name := range strings.Split(*flagMirrors, ",") {
+		switch strings.TrimSpace(name) {
+		case "":
+			continue
+		case "jsdelivr":
+			mirrors = append(mirrors, forge.JsDelivr{Owner: *flagOwner, Repo: *flagRepo, Branch: *flagBranch})
+		case "ghproxy":
+			mirrors = append(mirrors, forge.GhProxy{Upstream: primary})
+		case "gitea":
+			if *flagGiteaURL == "" {
+				return nil, nil, errors.New("-gitea-base-url is required when \"gitea\" is in -mirrors")
+			}
+			owner, repo := *flagGiteaOwner, *flagGiteaRepo
+			if owner == "" {
+				owner = *flagOwner
+			}
+			if repo == "" {
+				repo = *flagRepo
+			}
+			mirrors = append(mirrors, forge.Gitea{BaseURL: *flagGiteaURL, Owner: owner, Repo: repo, Branch: *flagBranch})
+		default:
+			return nil, nil, fmt.Errorf("unknown mirror %q", name)
+		}
+	}
+
+	return primary, mirrors, nil
+}
+
+func walkJS(rootPath string, primary forge.Forger, mirrors []forge.Forger) ([]pluginMeta, error) {
 	ret := []pluginMeta{}
 
 	err := filepath.WalkDir(rootPath, func(path string, d fs.DirEntry, err error) error {
@@ -89,7 +185,7 @@ func walkJS(rootPath string) ([]pluginMeta, error) {
 			return nil
 		}
 
-		meta, err := handleFile(path)
+		meta, err := handleFile(path, primary, mirrors)
 		if err != nil {
 			slog.Error("failed to handle javascript file", "path", path, "error", err.Error())
 			return nil
@@ -105,14 +201,21 @@ func walkJS(rootPath string) ([]pluginMeta, error) {
 	return ret, nil
 }
 
-func handleFile(path string) (pluginMeta, error) {
-	ret := pluginMeta{Path: path}
-
+func handleFile(path string, primary forge.Forger, mirrors []forge.Forger) (pluginMeta, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
-		return ret, fmt.Errorf("read file error: %w", err)
+		return pluginMeta{Path: path}, fmt.Errorf("read file error: %w", err)
 	}
 
+	return parseMeta(path, data, primary, mirrors)
+}
+
+// parseMeta extracts a pluginMeta from a userscript's raw contents. path is
+// kept separate from data so callers can parse bytes fetched over the
+// network (see revalidateOne) while still reporting the plugin's local path.
+func parseMeta(path string, data []byte, primary forge.Forger, mirrors []forge.Forger) (pluginMeta, error) {
+	ret := pluginMeta{Path: path}
+
 	meta := reMeta.FindSubmatch(data)
 	if len(meta) != 2 {
 		return ret, errors.New("no metadata found")
@@ -126,22 +229,39 @@ func handleFile(path string) (pluginMeta, error) {
 	ret.UpdateURLs = []string{}
 	ret.Depents = []string{}
 
+	var downloadURLOverride string
+
 	for _, item := range items {
 		value := string(item[2])
 		switch string(item[1]) {
 		case "name":
-			ret.Name = value
+			if ret.Name == "" {
+				ret.Name = value
+			}
 		case "homepageURL":
-			ret.HomePage = value
+			if ret.HomePage == "" {
+				ret.HomePage = value
+			}
 		case "license":
-			ret.License = value
+			if ret.License == "" {
+				ret.License = value
+			}
 		case "author":
-			ret.Author = value
+			if ret.Author == "" {
+				ret.Author = value
+			}
 		case "version":
-			ret.Version = value
+			if ret.Version == "" {
+				ret.Version = value
+			}
 		case "description":
-			ret.Description = value
+			if ret.Description == "" {
+				ret.Description = value
+			}
 		case "timestamp":
+			if ret.UpdateTime != "" {
+				continue
+			}
 			if ts, errParse := strconv.ParseInt(value, 10, 64); errParse == nil {
 				ret.UpdateTime = time.Unix(ts, 0).Local().Format(time.DateTime)
 				continue
@@ -152,16 +272,74 @@ func handleFile(path string) (pluginMeta, error) {
 		case "updateURL":
 			ret.UpdateURLs = append(ret.UpdateURLs, value)
 		case "etag":
-			ret.Etag = value
+			if ret.Etag == "" {
+				ret.Etag = value
+			}
 		case "depents":
 			ret.Depents = append(ret.Depents, value)
+		case "namespace":
+			if ret.Namespace == "" {
+				ret.Namespace = value
+			}
+		case "icon", "iconURL":
+			if ret.Icon == "" {
+				ret.Icon = value
+			}
+		case "match":
+			ret.Matches = append(ret.Matches, value)
+		case "include":
+			ret.Includes = append(ret.Includes, value)
+		case "exclude":
+			ret.Excludes = append(ret.Excludes, value)
+		case "grant":
+			ret.Grants = append(ret.Grants, value)
+		case "require":
+			ret.Requires = append(ret.Requires, value)
+		case "supportURL":
+			if ret.SupportURL == "" {
+				ret.SupportURL = value
+			}
+		case "downloadURL":
+			if downloadURLOverride == "" {
+				downloadURLOverride = value
+			}
+		case "run-at":
+			if ret.RunAt == "" {
+				ret.RunAt = value
+			}
+		default:
+			if locale, ok := strings.CutPrefix(string(item[1]), "name:"); ok {
+				if ret.Names == nil {
+					ret.Names = map[string]string{}
+				}
+				if _, seen := ret.Names[locale]; !seen {
+					ret.Names[locale] = value
+				}
+			} else if locale, ok := strings.CutPrefix(string(item[1]), "description:"); ok {
+				if ret.Descriptions == nil {
+					ret.Descriptions = map[string]string{}
+				}
+				if _, seen := ret.Descriptions[locale]; !seen {
+					ret.Descriptions[locale] = value
+				}
+			}
 		}
 	}
 
-	pathItems := strings.Split(path, string(filepath.Separator))
-	for i := range pathItems {
-		pathItems[i] = url.PathEscape(pathItems[i])
+	if ret.Name == "" {
+		slog.Warn("plugin metadata missing required field", "path", path, "field", "name")
+	}
+	if ret.Version == "" {
+		slog.Warn("plugin metadata missing required field", "path", path, "field", "version")
+	}
+
+	relPath := filepath.ToSlash(path)
+	ret.DownloadURL = primary.RawURL(relPath)
+	for _, mirror := range mirrors {
+		ret.MirrorURLs = append(ret.MirrorURLs, mirror.RawURL(relPath))
+	}
+	if downloadURLOverride != "" {
+		ret.DownloadURL = downloadURLOverride
 	}
-	ret.DownloadURL, _ = url.JoinPath(ghURLPrefix, pathItems...)
 	return ret, nil
 }
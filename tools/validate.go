@@ -0,0 +1,202 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/sealdice/javascript/tools/forge"
+)
+
+const (
+	defaultCachePath = ".scripts-cache.json"
+	defaultStaleness = 48 * time.Hour
+)
+
+// cacheEntry records the conditional-request state for a single UpdateURL,
+// so repeated -validate runs within the staleness window skip the network
+// entirely.
+type cacheEntry struct {
+	Etag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"last_modified,omitempty"`
+	FetchedAt    time.Time `json:"fetched_at"`
+}
+
+type cacheStore map[string]cacheEntry
+
+func loadCache(path string) (cacheStore, error) {
+	store := cacheStore{}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, &store); err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+func (c cacheStore) save(path string) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// loadPreviousMetas reads a scripts.json produced by an earlier run, keyed
+// by plugin path, so -validate knows which freshly-walked plugins are
+// already known and can be revalidated instead of trusted blindly.
+func loadPreviousMetas(path string) (map[string]pluginMeta, error) {
+	previous := map[string]pluginMeta{}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return previous, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var metas []pluginMeta
+	if err := json.Unmarshal(data, &metas); err != nil {
+		return nil, err
+	}
+	for _, meta := range metas {
+		previous[meta.Path] = meta
+	}
+	return previous, nil
+}
+
+// validate revalidates every plugin in metas that also appears in the
+// scripts.json at outputPath, by issuing a conditional GET against its
+// UpdateURLs. A plugin with a cache entry for any of its UpdateURLs fetched
+// within the staleness window keeps its previous meta without a request.
+// Plugins absent from the previous scripts.json, with no UpdateURLs, or
+// whose every request fails keep their freshly-walked meta. A plugin with
+// no prior Etag/UpdateTime is still fetched, just without conditional
+// headers.
+func validate(metas []pluginMeta, outputPath, cachePath string, staleness time.Duration, primary forge.Forger, mirrors []forge.Forger) ([]pluginMeta, error) {
+	previous, err := loadPreviousMetas(outputPath)
+	if err != nil {
+		return nil, fmt.Errorf("load previous scripts.json: %w", err)
+	}
+
+	cache, err := loadCache(cachePath)
+	if err != nil {
+		return nil, fmt.Errorf("load cache: %w", err)
+	}
+
+	client := &http.Client{Timeout: 15 * time.Second}
+	now := time.Now()
+
+	for i, meta := range metas {
+		prev, known := previous[meta.Path]
+		if !known || len(meta.UpdateURLs) == 0 {
+			continue
+		}
+
+		fresh := false
+		for _, url := range meta.UpdateURLs {
+			if entry, ok := cache[url]; ok && now.Sub(entry.FetchedAt) < staleness {
+				fresh = true
+				break
+			}
+		}
+		if fresh {
+			metas[i] = prev
+			continue
+		}
+
+		var updated *pluginMeta
+		var err error
+		for _, url := range meta.UpdateURLs {
+			var entry cacheEntry
+			updated, entry, err = revalidateOne(client, url, prev, primary, mirrors)
+			if err != nil {
+				slog.Warn("failed to revalidate plugin against upstream", "path", meta.Path, "url", url, "error", err.Error())
+				continue
+			}
+
+			cache[url] = entry
+			break
+		}
+		if err != nil {
+			continue
+		}
+
+		if updated != nil {
+			metas[i] = *updated
+		}
+	}
+
+	if err := cache.save(cachePath); err != nil {
+		return nil, fmt.Errorf("save cache: %w", err)
+	}
+	return metas, nil
+}
+
+// revalidateOne issues a single conditional GET against url. It returns the
+// prior meta verbatim on 304, a freshly parsed meta on 200, or a nil meta
+// (caller keeps the freshly-walked local one) for any other outcome.
+func revalidateOne(client *http.Client, url string, prev pluginMeta, primary forge.Forger, mirrors []forge.Forger) (*pluginMeta, cacheEntry, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, cacheEntry{}, err
+	}
+	if prev.Etag != "" {
+		req.Header.Set("If-None-Match", prev.Etag)
+	}
+	if t, errParse := time.ParseInLocation(time.DateTime, prev.UpdateTime, time.Local); errParse == nil {
+		req.Header.Set("If-Modified-Since", t.UTC().Format(http.TimeFormat))
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, cacheEntry{}, err
+	}
+	defer resp.Body.Close()
+
+	entry := cacheEntry{
+		Etag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		FetchedAt:    time.Now(),
+	}
+
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		return &prev, entry, nil
+	case http.StatusOK:
+		data, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, entry, err
+		}
+
+		parsed, err := parseMeta(prev.Path, data, primary, mirrors)
+		if err != nil {
+			return nil, entry, err
+		}
+
+		if parsed.Etag == "" {
+			parsed.Etag = entry.Etag
+		}
+		if parsed.UpdateTime == "" && entry.LastModified != "" {
+			if t, errParse := http.ParseTime(entry.LastModified); errParse == nil {
+				parsed.UpdateTime = t.Local().Format(time.DateTime)
+			}
+		}
+		return &parsed, entry, nil
+	default:
+		return nil, entry, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+}